@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// User is a registered account that owns tokens and entries.
+type User struct {
+	ID       int64
+	Username string
+	Verified bool
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func hasScope(scopes, required string) bool {
+	for value := range strings.SplitSeq(scopes, ",") {
+		if strings.TrimSpace(value) == required {
+			return true
+		}
+	}
+	return false
+}
+
+// createUser inserts a new user and returns its id. Users created through
+// this admin-bootstrapped path are trusted immediately, unlike self-service
+// registrations which start unverified until they confirm their email.
+func createUser(username string) (int64, error) {
+	res, err := db.Exec(
+		"INSERT INTO users (username, created_at, verified) VALUES (?, ?, 1)",
+		username, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// issueToken creates a token for the given user with the given scopes and
+// optional expiry, returning the plaintext token (only ever shown once).
+func issueToken(userID int64, scopes string, expiresAt *time.Time) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	var expiresStr any
+	if expiresAt != nil {
+		expiresStr = expiresAt.UTC().Format(time.RFC3339)
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO tokens (user_id, token_hash, scopes, created_at, expires_at, revoked) VALUES (?, ?, ?, ?, ?, 0)",
+		userID, hashToken(token), scopes, time.Now().UTC().Format(time.RFC3339), expiresStr,
+	)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func revokeToken(tokenID int64) error {
+	_, err := db.Exec("UPDATE tokens SET revoked = 1 WHERE id = ?", tokenID)
+	return err
+}
+
+// lookupToken resolves a presented token to its owning user, enforcing
+// revocation, expiry, and the scope required by the route.
+func lookupToken(presented, requiredScope string) (*User, error) {
+	var userID int64
+	var scopes string
+	var expiresAt sql.NullString
+	var revoked bool
+
+	err := db.QueryRow(
+		"SELECT user_id, scopes, expires_at, revoked FROM tokens WHERE token_hash = ?",
+		hashToken(presented),
+	).Scan(&userID, &scopes, &expiresAt, &revoked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("unknown token")
+		}
+		return nil, err
+	}
+
+	if revoked {
+		return nil, fmt.Errorf("token revoked")
+	}
+	if expiresAt.Valid && expiresAt.String != "" {
+		expiry, err := time.Parse(time.RFC3339, expiresAt.String)
+		if err == nil && time.Now().UTC().After(expiry) {
+			return nil, fmt.Errorf("token expired")
+		}
+	}
+	if requiredScope != "" && !hasScope(scopes, requiredScope) {
+		return nil, fmt.Errorf("token missing scope %q", requiredScope)
+	}
+
+	var username string
+	var verified bool
+	if err := db.QueryRow("SELECT username, verified FROM users WHERE id = ?", userID).Scan(&username, &verified); err != nil {
+		return nil, err
+	}
+
+	return &User{ID: userID, Username: username, Verified: verified}, nil
+}
+
+func userFromContext(ctx context.Context) *User {
+	user, _ := ctx.Value(userContextKey).(*User)
+	return user
+}
+
+// authWrapper resolves the token presented in the configured auth header,
+// checks it carries requiredScope, and injects the resolved user into the
+// request context before calling next.
+func authWrapper(requiredScope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		presented := r.Header.Get(cfg.Section("general").Key("authheader").String())
+		if presented == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := lookupToken(presented, requiredScope)
+		if err != nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// combinedAuth accepts either a logged-in session or an API token carrying
+// requiredScope, but only from a verified user, so self-registered accounts
+// can't write until they've confirmed their email.
+func combinedAuth(requiredScope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if user := userFromSession(r); user != nil {
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			next(w, r.WithContext(ctx))
+			return
+		}
+
+		presented := r.Header.Get(cfg.Section("general").Key("authheader").String())
+		if presented == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := lookupToken(presented, requiredScope)
+		if err != nil || !user.Verified {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// adminAuthWrapper gates the bootstrap endpoints behind the admin secret,
+// since the first user can't yet hold a token.
+func adminAuthWrapper(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		presented := r.Header.Get(cfg.Section("general").Key("authheader").String())
+		want := cfg.Section("general").Key("adminsecret").String()
+		if presented == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(want)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func createUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var params struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if params.Username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := createUser(params.Username)
+	if err != nil {
+		http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"id": id, "username": params.Username})
+}
+
+func issueTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var params struct {
+		UserID    int64    `json:"user_id"`
+		Scopes    []string `json:"scopes"`
+		ExpiresIn string   `json:"expires_in"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if params.UserID == 0 || len(params.Scopes) == 0 {
+		http.Error(w, "user_id and scopes are required", http.StatusBadRequest)
+		return
+	}
+
+	var expiresAt *time.Time
+	if params.ExpiresIn != "" {
+		d, err := time.ParseDuration(params.ExpiresIn)
+		if err != nil {
+			http.Error(w, "Invalid expires_in: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		expiry := time.Now().UTC().Add(d)
+		expiresAt = &expiry
+	}
+
+	token, err := issueToken(params.UserID, strings.Join(params.Scopes, ","), expiresAt)
+	if err != nil {
+		http.Error(w, "Failed to issue token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"token": token})
+}
+
+func revokeTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var params struct {
+		TokenID int64 `json:"token_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if params.TokenID == 0 {
+		http.Error(w, "token_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := revokeToken(params.TokenID); err != nil {
+		http.Error(w, "Failed to revoke token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, "success")
+}
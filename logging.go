@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var slogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func newRequestID() string {
+	raw := make([]byte, 8)
+	rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
+
+// loggingMiddleware logs the remote address, tag (if any), status, and
+// latency of each request under a per-request id once the handler returns.
+func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqID := newRequestID()
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		remoteAddr := r.RemoteAddr
+		if idx := strings.LastIndex(remoteAddr, ":"); idx != -1 {
+			remoteAddr = remoteAddr[:idx]
+		}
+
+		next(rec, r)
+
+		slogger.Info("request",
+			"request_id", reqID,
+			"remote_addr", remoteAddr,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"tag", r.URL.Query().Get("tag"),
+			"status", rec.status,
+			"latency", time.Since(start).String(),
+		)
+	}
+}
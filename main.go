@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/smtp"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/cloudflare/tableflip"
 	_ "github.com/mattn/go-sqlite3"
 	"gopkg.in/ini.v1"
 )
@@ -23,9 +27,66 @@ func initDB() {
 	if err != nil {
 		panic(err)
 	}
-	// Create table if it doesn't exist
-	createTableSQL := `CREATE TABLE IF NOT EXISTS entries (id INTEGER PRIMARY KEY AUTOINCREMENT, tag TEXT UNIQUE, url TEXT);`
-	db.Exec(createTableSQL)
+
+	db.Exec(`CREATE TABLE IF NOT EXISTS entries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		tag TEXT UNIQUE,
+		url TEXT,
+		user_id INTEGER,
+		created_at TEXT,
+		expires_at TEXT,
+		max_uses INTEGER,
+		uses INTEGER NOT NULL DEFAULT 0
+	);`)
+	db.Exec(`CREATE TABLE IF NOT EXISTS entry_clicks (id INTEGER PRIMARY KEY AUTOINCREMENT, tag TEXT NOT NULL, clicked_at TEXT NOT NULL);`)
+	db.Exec(`CREATE TABLE IF NOT EXISTS users (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT UNIQUE NOT NULL, created_at TEXT NOT NULL);`)
+	addColumnIfMissing("users", "email", "TEXT")
+	addColumnIfMissing("users", "password_hash", "TEXT")
+	addColumnIfMissing("users", "verified", "INTEGER NOT NULL DEFAULT 0")
+	db.Exec(`CREATE TABLE IF NOT EXISTS verification_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT UNIQUE NOT NULL,
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL,
+		used INTEGER NOT NULL DEFAULT 0
+	);`)
+	db.Exec(`CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_hash TEXT UNIQUE NOT NULL,
+		user_id INTEGER NOT NULL,
+		created_at TEXT NOT NULL,
+		expires_at TEXT NOT NULL
+	);`)
+	db.Exec(`CREATE TABLE IF NOT EXISTS tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token_hash TEXT UNIQUE NOT NULL,
+		scopes TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		expires_at TEXT,
+		revoked INTEGER NOT NULL DEFAULT 0
+	);`)
+	db.Exec(`CREATE TABLE IF NOT EXISTS notification_queue (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		backend TEXT NOT NULL,
+		level TEXT NOT NULL,
+		body TEXT NOT NULL,
+		meta TEXT,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);`)
+}
+
+// addColumnIfMissing adds a column to an existing table, ignoring the
+// "duplicate column" error sqlite raises when it's already there. This repo
+// has no migration framework, so schema additions are just idempotent DDL.
+func addColumnIfMissing(table, column, definition string) {
+	_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		slogger.Error("failed to add column", "table", table, "column", column, "err", err)
+	}
 }
 
 func ipHandler(w http.ResponseWriter, r *http.Request) {
@@ -41,8 +102,10 @@ func notifyHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var params struct {
-		Level string `json:"level"`
-		Body  string `json:"body"`
+		Level   string            `json:"level"`
+		Body    string            `json:"body"`
+		Channel string            `json:"channel"`
+		Meta    map[string]string `json:"meta"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
 		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
@@ -53,77 +116,39 @@ func notifyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	recipients := []string{}
-	for value := range strings.SplitSeq(cfg.Section("smtp").Key("sendto").String(), ",") {
-		trimmedValue := strings.TrimSpace(value)
-		recipients = append(recipients, trimmedValue)
-	}
-
-	auth := smtp.PlainAuth("", cfg.Section("smtp").Key("username").String(), cfg.Section("smtp").Key("password").String(), cfg.Section("smtp").Key("server").String())
-	err := smtp.SendMail(
-		cfg.Section("smtp").Key("server").String()+":"+cfg.Section("smtp").Key("port").String(),
-		auth,
-		cfg.Section("smtp").Key("username").String(),
-		recipients,
-		[]byte(params.Level+"\r\n\r\n"+params.Body),
-	)
-
-	if err != nil {
-		http.Error(w, "RequestFailed: "+err.Error(), http.StatusInternalServerError)
-		return
+	backends := routeForLevel(params.Level)
+	if params.Channel != "" {
+		backends = strings.Split(params.Channel, ",")
 	}
-	fmt.Fprint(w, "success")
-}
 
-func saveHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
-		return
-	}
+	var failures []string
+	for _, name := range backends {
+		name = strings.TrimSpace(name)
+		notifier, ok := notifiers[name]
+		if !ok {
+			failures = append(failures, name+": unknown backend")
+			continue
+		}
 
-	var params struct {
-		Tag string `json:"tag"`
-		URL string `json:"url"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
-		return
+		if err := notifier.Send(params.Level, params.Body, params.Meta); err != nil {
+			if qerr := enqueueNotification(name, params.Level, params.Body, params.Meta); qerr != nil {
+				failures = append(failures, name+": "+err.Error())
+			}
+		}
 	}
 
-	_, err := db.Exec("INSERT INTO entries (tag, url) VALUES (?, ?)", params.Tag, params.URL)
-	if err != nil {
-		http.Error(w, "Failed to save data: "+err.Error(), http.StatusInternalServerError)
+	if len(failures) > 0 {
+		http.Error(w, "RequestFailed: "+strings.Join(failures, "; "), http.StatusInternalServerError)
 		return
 	}
 	fmt.Fprint(w, "success")
 }
 
-func retrieveHandler(w http.ResponseWriter, r *http.Request) {
-	tag := r.URL.Query().Get("tag")
-	if tag == "" {
-		http.Error(w, "Tag is required", http.StatusBadRequest)
-		return
-	}
-
-	var url string
-	err := db.QueryRow("SELECT url FROM entries WHERE tag = ?", tag).Scan(&url)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "No URL found for the given tag", http.StatusNotFound)
-		} else {
-			http.Error(w, "Failed to retrieve data: "+err.Error(), http.StatusInternalServerError)
-		}
-		return
-	}
-
-	http.Redirect(w, r, url, http.StatusFound)
-}
-
 func parseConfig() bool {
 	var err error
 	cfg, err = ini.Load("config.ini")
 	if err != nil {
-		fmt.Printf("Failed to load config file: %v", err)
+		slogger.Error("failed to load config file", "err", err)
 		return false
 	}
 	smtpKeys := []string{"server", "port", "username", "password", "sendto"}
@@ -131,9 +156,9 @@ func parseConfig() bool {
 		return false
 	}
 	if !checkConfig("general", []string{
-		"database", "secret", "authheader", "sslport",
+		"database", "adminsecret", "authheader", "sslport",
 		"httpport", "sslcert", "sslkey", "httpenabled",
-		"sslenabled",
+		"sslenabled", "sessionkey", "baseurl",
 	}) {
 		return false
 	}
@@ -141,37 +166,40 @@ func parseConfig() bool {
 	return true
 }
 
-func authWrapper(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get(cfg.Section("general").Key("authheader").String())
-		if auth == "" {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		if auth != cfg.Section("general").Key("secret").String() {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		next(w, r)
-	}
-}
-
 func checkConfig(section string, keys []string) bool {
 	if cfg.Section(section) == nil {
-		fmt.Printf("Missing config section: %s", section)
+		slogger.Error("missing config section", "section", section)
 		return false
 	}
 	for _, value := range keys {
 		if cfg.Section(section).Key(value).String() == "" {
-			fmt.Printf("Missing key: '%s' under section: %s\n", value, section)
+			slogger.Error("missing config key", "section", section, "key", value)
 			return false
 		}
 	}
 	return true
 }
 
+// buildMux registers all routes, wrapped with the logging middleware, onto
+// a fresh mux so a reload can rebuild routing from freshly parsed config.
+func buildMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ip", loggingMiddleware(ipHandler))
+	mux.HandleFunc("/notify", loggingMiddleware(authWrapper("notify", notifyHandler)))
+	mux.HandleFunc("/puturl", loggingMiddleware(combinedAuth("puturl", saveHandler)))
+	mux.HandleFunc("/geturl", loggingMiddleware(retrieveHandler))
+	mux.HandleFunc("/stats", loggingMiddleware(authWrapper("puturl", statsHandler)))
+	mux.HandleFunc("/users/create", loggingMiddleware(adminAuthWrapper(createUserHandler)))
+	mux.HandleFunc("/tokens/issue", loggingMiddleware(adminAuthWrapper(issueTokenHandler)))
+	mux.HandleFunc("/tokens/revoke", loggingMiddleware(adminAuthWrapper(revokeTokenHandler)))
+	mux.HandleFunc("/register", loggingMiddleware(registerHandler))
+	mux.HandleFunc("/verify", loggingMiddleware(verifyHandler))
+	mux.HandleFunc("/login", loggingMiddleware(loginHandler))
+	mux.HandleFunc("/logout", loggingMiddleware(logoutHandler))
+	mux.HandleFunc("/dashboard", loggingMiddleware(sessionAuth(dashboardHandler)))
+	return mux
+}
+
 func main() {
 	if !parseConfig() {
 		os.Exit(1)
@@ -179,39 +207,95 @@ func main() {
 	initDB()
 	defer db.Close()
 
-	http.HandleFunc("/ip", ipHandler)
-	http.HandleFunc("/notify", authWrapper(notifyHandler))
-	http.HandleFunc("/puturl", authWrapper(saveHandler))
-	http.HandleFunc("/geturl", retrieveHandler)
+	notifiers = buildNotifiers()
+	startNotificationRetryWorker()
+	startExpiryPurgeWorker()
+
+	pidFile := cfg.Section("general").Key("pidfile").String()
+	if pidFile == "" {
+		pidFile = "jakkserv.pid"
+	}
+
+	upg, err := tableflip.New(tableflip.Options{PIDFile: pidFile})
+	if err != nil {
+		slogger.Error("failed to create upgrader", "err", err)
+		os.Exit(1)
+	}
+	defer upg.Stop()
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGHUP)
+		for range sig {
+			slogger.Info("received SIGHUP, reloading config and upgrading")
+			if !parseConfig() {
+				slogger.Error("reload aborted: invalid config")
+				continue
+			}
+			notifiers = buildNotifiers()
+			if err := upg.Upgrade(); err != nil {
+				slogger.Error("upgrade failed", "err", err)
+			}
+		}
+	}()
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		slogger.Info("received shutdown signal, draining")
+		upg.Stop()
+	}()
+
+	mux := buildMux()
+	var servers []*http.Server
 
 	if cfg.Section("general").Key("httpenabled").String() == "true" {
+		ln, err := upg.Fds.Listen("tcp", ":"+cfg.Section("general").Key("httpport").String())
+		if err != nil {
+			slogger.Error("could not bind http listener", "err", err)
+			os.Exit(1)
+		}
+		srv := &http.Server{Handler: mux}
+		servers = append(servers, srv)
 		go func() {
-			err := http.ListenAndServe(
-				":"+cfg.Section("general").Key("httpport").String(),
-				nil,
-			)
-			if err != nil {
-				fmt.Printf("could not start http server: %v\n", err)
-				os.Exit(1)
+			if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+				slogger.Error("http server error", "err", err)
 			}
 		}()
 	}
 
 	if cfg.Section("general").Key("sslenabled").String() == "true" {
+		ln, err := upg.Fds.Listen("tcp", ":"+cfg.Section("general").Key("sslport").String())
+		if err != nil {
+			slogger.Error("could not bind ssl listener", "err", err)
+			os.Exit(1)
+		}
+		srv := &http.Server{Handler: mux}
+		servers = append(servers, srv)
 		go func() {
-			err := http.ListenAndServeTLS(
-				":"+cfg.Section("general").Key("sslport").String(),
-				cfg.Section("general").Key("sslcert").String(),
-				cfg.Section("general").Key("sslkey").String(),
-				nil,
-			)
-			if err != nil {
-				fmt.Printf("could not start ssl server: %s\n", err)
-				os.Exit(1)
+			err := srv.ServeTLS(ln, cfg.Section("general").Key("sslcert").String(), cfg.Section("general").Key("sslkey").String())
+			if err != nil && err != http.ErrServerClosed {
+				slogger.Error("ssl server error", "err", err)
 			}
 		}()
 	}
 
-	// todo: add proper shutdown logic
-	select {}
+	if err := upg.Ready(); err != nil {
+		slogger.Error("upgrader not ready", "err", err)
+		os.Exit(1)
+	}
+
+	<-upg.Exit()
+
+	drainTimeout := 10 * time.Second
+	if d, err := time.ParseDuration(cfg.Section("general").Key("draintimeout").String()); err == nil {
+		drainTimeout = d
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		srv.Shutdown(ctx)
+	}
 }
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Notifier delivers a single notification to a backend-specific destination.
+type Notifier interface {
+	Send(level, body string, meta map[string]string) error
+}
+
+var notifiers map[string]Notifier
+
+// buildNotifiers wires up the Notifier backends that have been configured.
+// smtp is always available since [smtp] is a required config section;
+// webhook and matrix are opt-in based on whether their sections are filled in.
+func buildNotifiers() map[string]Notifier {
+	built := map[string]Notifier{
+		"smtp": &smtpNotifier{},
+	}
+
+	if cfg.Section("webhook").Key("url").String() != "" {
+		built["webhook"] = &webhookNotifier{}
+	}
+
+	if cfg.Section("matrix").Key("homeserver").String() != "" &&
+		cfg.Section("matrix").Key("roomid").String() != "" &&
+		cfg.Section("matrix").Key("accesstoken").String() != "" {
+		built["matrix"] = &matrixNotifier{}
+	}
+
+	return built
+}
+
+// routeForLevel returns the backend names configured to receive notifications
+// at the given level, falling back to smtp if no routing rule is set.
+func routeForLevel(level string) []string {
+	rule := cfg.Section("routing").Key(level).String()
+	if rule == "" {
+		return []string{"smtp"}
+	}
+
+	backends := []string{}
+	for value := range strings.SplitSeq(rule, ",") {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			backends = append(backends, trimmed)
+		}
+	}
+	return backends
+}
+
+type smtpNotifier struct{}
+
+func (n *smtpNotifier) Send(level, body string, meta map[string]string) error {
+	recipients := []string{}
+	for value := range strings.SplitSeq(cfg.Section("smtp").Key("sendto").String(), ",") {
+		recipients = append(recipients, strings.TrimSpace(value))
+	}
+
+	auth := smtp.PlainAuth("", cfg.Section("smtp").Key("username").String(), cfg.Section("smtp").Key("password").String(), cfg.Section("smtp").Key("server").String())
+	return smtp.SendMail(
+		cfg.Section("smtp").Key("server").String()+":"+cfg.Section("smtp").Key("port").String(),
+		auth,
+		cfg.Section("smtp").Key("username").String(),
+		recipients,
+		[]byte(level+"\r\n\r\n"+body),
+	)
+}
+
+type webhookNotifier struct{}
+
+var webhookDefaultTemplate = `{"level":{{.Level | printf "%q"}},"body":{{.Body | printf "%q"}}}`
+
+func (n *webhookNotifier) Send(level, body string, meta map[string]string) error {
+	tmplText := cfg.Section("webhook").Key("template").String()
+	if tmplText == "" {
+		tmplText = webhookDefaultTemplate
+	}
+
+	tmpl, err := template.New("webhook").Parse(tmplText)
+	if err != nil {
+		return fmt.Errorf("invalid webhook template: %w", err)
+	}
+
+	var payload bytes.Buffer
+	if err := tmpl.Execute(&payload, struct {
+		Level string
+		Body  string
+		Meta  map[string]string
+	}{level, body, meta}); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, cfg.Section("webhook").Key("url").String(), &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key, value := cfg.Section("webhook").Key("headerkey").String(), cfg.Section("webhook").Key("headervalue").String(); key != "" {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type matrixNotifier struct{}
+
+func (n *matrixNotifier) Send(level, body string, meta map[string]string) error {
+	homeserver := cfg.Section("matrix").Key("homeserver").String()
+	roomID := cfg.Section("matrix").Key("roomid").String()
+	token := cfg.Section("matrix").Key("accesstoken").String()
+	txnID := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	url := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", homeserver, roomID, txnID)
+	payload, err := json.Marshal(map[string]string{
+		"msgtype": "m.text",
+		"body":    level + ": " + body,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("matrix returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// enqueueNotification persists a failed delivery for the retry worker to
+// pick up later, so a transient backend outage doesn't drop the notification.
+func enqueueNotification(backend, level, body string, meta map[string]string) error {
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	_, err = db.Exec(
+		"INSERT INTO notification_queue (backend, level, body, meta, attempts, next_attempt_at, created_at) VALUES (?, ?, ?, ?, 0, ?, ?)",
+		backend, level, body, string(metaJSON), now.Format(time.RFC3339), now.Format(time.RFC3339),
+	)
+	return err
+}
+
+func notificationBackoff(attempts int) time.Duration {
+	backoff := 30 * time.Second
+	for i := 0; i < attempts; i++ {
+		backoff *= 2
+		if backoff > time.Hour {
+			return time.Hour
+		}
+	}
+	return backoff
+}
+
+// startNotificationRetryWorker periodically retries queued notifications
+// with exponential backoff, so deliveries survive restarts and transient
+// backend failures.
+func startNotificationRetryWorker() {
+	go func() {
+		ticker := time.NewTicker(15 * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			retryQueuedNotifications()
+		}
+	}()
+}
+
+func retryQueuedNotifications() {
+	rows, err := db.Query(
+		"SELECT id, backend, level, body, meta, attempts FROM notification_queue WHERE next_attempt_at <= ?",
+		time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		slogger.Error("notification retry query failed", "err", err)
+		return
+	}
+	defer rows.Close()
+
+	type queued struct {
+		id       int64
+		backend  string
+		level    string
+		body     string
+		meta     string
+		attempts int
+	}
+	var pending []queued
+	for rows.Next() {
+		var q queued
+		if err := rows.Scan(&q.id, &q.backend, &q.level, &q.body, &q.meta, &q.attempts); err != nil {
+			continue
+		}
+		pending = append(pending, q)
+	}
+
+	for _, q := range pending {
+		notifier, ok := notifiers[q.backend]
+		if !ok {
+			db.Exec("DELETE FROM notification_queue WHERE id = ?", q.id)
+			continue
+		}
+
+		var meta map[string]string
+		json.Unmarshal([]byte(q.meta), &meta)
+
+		if err := notifier.Send(q.level, q.body, meta); err != nil {
+			attempts := q.attempts + 1
+			next := time.Now().UTC().Add(notificationBackoff(attempts))
+			db.Exec("UPDATE notification_queue SET attempts = ?, next_attempt_at = ? WHERE id = ?", attempts, next.Format(time.RFC3339), q.id)
+			continue
+		}
+
+		db.Exec("DELETE FROM notification_queue WHERE id = ?", q.id)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func saveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var params struct {
+		Tag       string `json:"tag"`
+		URL       string `json:"url"`
+		ExpiresIn string `json:"expires_in"`
+		MaxUses   *int   `json:"max_uses"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var expiresAt any
+	if params.ExpiresIn != "" {
+		d, err := time.ParseDuration(params.ExpiresIn)
+		if err != nil {
+			http.Error(w, "Invalid expires_in: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		expiresAt = time.Now().UTC().Add(d).Format(time.RFC3339)
+	}
+
+	var maxUses any
+	if params.MaxUses != nil {
+		maxUses = *params.MaxUses
+	}
+
+	user := userFromContext(r.Context())
+	_, err := db.Exec(
+		"INSERT INTO entries (tag, url, user_id, created_at, expires_at, max_uses, uses) VALUES (?, ?, ?, ?, ?, ?, 0)",
+		params.Tag, params.URL, user.ID, time.Now().UTC().Format(time.RFC3339), expiresAt, maxUses,
+	)
+	if err != nil {
+		http.Error(w, "Failed to save data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, "success")
+}
+
+func retrieveHandler(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		http.Error(w, "Tag is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Failed to retrieve data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var url string
+	var expiresAt sql.NullString
+	var maxUses sql.NullInt64
+	var uses int64
+	err = tx.QueryRow("SELECT url, expires_at, max_uses, uses FROM entries WHERE tag = ?", tag).Scan(&url, &expiresAt, &maxUses, &uses)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "No URL found for the given tag", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve data: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if entryExpired(expiresAt, maxUses, uses) {
+		tx.Exec("DELETE FROM entries WHERE tag = ?", tag)
+		tx.Commit()
+		http.Error(w, "This URL has expired or reached its use limit", http.StatusGone)
+		return
+	}
+
+	if _, err := tx.Exec("UPDATE entries SET uses = uses + 1 WHERE tag = ?", tag); err != nil {
+		http.Error(w, "Failed to retrieve data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec("INSERT INTO entry_clicks (tag, clicked_at) VALUES (?, ?)", tag, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		http.Error(w, "Failed to retrieve data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Failed to retrieve data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// entryExpired reports whether an entry has passed its expiry time or
+// already reached its max_uses limit and should be rejected/purged.
+func entryExpired(expiresAt sql.NullString, maxUses sql.NullInt64, uses int64) bool {
+	if expiresAt.Valid && expiresAt.String != "" {
+		expiry, err := time.Parse(time.RFC3339, expiresAt.String)
+		if err == nil && time.Now().UTC().After(expiry) {
+			return true
+		}
+	}
+	if maxUses.Valid && uses >= maxUses.Int64 {
+		return true
+	}
+	return false
+}
+
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		http.Error(w, "Tag is required", http.StatusBadRequest)
+		return
+	}
+
+	var createdAt sql.NullString
+	var expiresAt sql.NullString
+	var maxUses sql.NullInt64
+	var uses int64
+	err := db.QueryRow("SELECT created_at, expires_at, max_uses, uses FROM entries WHERE tag = ?", tag).Scan(&createdAt, &expiresAt, &maxUses, &uses)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "No URL found for the given tag", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve stats: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	rows, err := db.Query("SELECT clicked_at FROM entry_clicks WHERE tag = ? ORDER BY clicked_at", tag)
+	if err != nil {
+		http.Error(w, "Failed to retrieve stats: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	clicks := []string{}
+	for rows.Next() {
+		var clickedAt string
+		if err := rows.Scan(&clickedAt); err != nil {
+			continue
+		}
+		clicks = append(clicks, clickedAt)
+	}
+
+	var remainingUses any
+	if maxUses.Valid {
+		remainingUses = maxUses.Int64 - uses
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"created_at":     createdAt.String,
+		"expires_at":     expiresAt.String,
+		"remaining_uses": remainingUses,
+		"uses":           uses,
+		"click_history":  clicks,
+	})
+}
+
+// startExpiryPurgeWorker periodically deletes entries that have expired
+// or been fully consumed, so they don't linger after a reader never
+// bothered to access them again.
+func startExpiryPurgeWorker() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			purgeExpiredEntries()
+		}
+	}()
+}
+
+func purgeExpiredEntries() {
+	now := time.Now().UTC().Format(time.RFC3339)
+	db.Exec("DELETE FROM entries WHERE expires_at IS NOT NULL AND expires_at != '' AND expires_at <= ?", now)
+	db.Exec("DELETE FROM entries WHERE max_uses IS NOT NULL AND uses >= max_uses")
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"net/smtp"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+const sessionCookieName = "jakksession"
+
+// dummyPasswordHash is compared against when a login's username doesn't
+// exist, so bcrypt always runs and a missing user can't be distinguished
+// from a wrong password by response timing.
+const dummyPasswordHash = "$2a$10$CwTycUXWue0Thq9StjUM0uJ8g7Qv3wLnDbGFPnHnDMxEh8j8bJ8Ze"
+
+func renderTemplate(w http.ResponseWriter, name string, data any) {
+	if err := templates.ExecuteTemplate(w, name, data); err != nil {
+		http.Error(w, "Failed to render page: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func signSessionID(sessionID string) string {
+	mac := hmac.New(sha256.New, []byte(cfg.Section("general").Key("sessionkey").String()))
+	mac.Write([]byte(sessionID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func setSessionCookie(w http.ResponseWriter, sessionID string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    sessionID + "." + signSessionID(sessionID),
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+func hashSessionID(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:])
+}
+
+// userFromSession validates the session cookie's HMAC signature, then looks
+// up the still-live session in the database and resolves its owning user.
+func userFromSession(r *http.Request) *User {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+
+	sep := len(cookie.Value) - 64
+	if sep <= 0 {
+		return nil
+	}
+	sessionID, signature := cookie.Value[:sep-1], cookie.Value[sep:]
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(signSessionID(sessionID))) != 1 {
+		return nil
+	}
+
+	var userID int64
+	var expiresAt string
+	err = db.QueryRow("SELECT user_id, expires_at FROM sessions WHERE session_hash = ?", hashSessionID(sessionID)).Scan(&userID, &expiresAt)
+	if err != nil {
+		return nil
+	}
+	if expiry, err := time.Parse(time.RFC3339, expiresAt); err != nil || time.Now().UTC().After(expiry) {
+		return nil
+	}
+
+	var username string
+	var verified bool
+	if err := db.QueryRow("SELECT username, verified FROM users WHERE id = ?", userID).Scan(&username, &verified); err != nil {
+		return nil
+	}
+
+	return &User{ID: userID, Username: username, Verified: verified}
+}
+
+// sessionAuth gates browser pages behind a logged-in session, bouncing back
+// to the login form rather than returning a bare 401 like the API routes do.
+func sessionAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if userFromSession(r) == nil {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func sendVerificationEmail(to, link string) error {
+	auth := smtp.PlainAuth("", cfg.Section("smtp").Key("username").String(), cfg.Section("smtp").Key("password").String(), cfg.Section("smtp").Key("server").String())
+	return smtp.SendMail(
+		cfg.Section("smtp").Key("server").String()+":"+cfg.Section("smtp").Key("port").String(),
+		auth,
+		cfg.Section("smtp").Key("username").String(),
+		[]string{to},
+		[]byte("Verify your account\r\n\r\nClick to verify: "+link),
+	)
+}
+
+func registerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		renderTemplate(w, "register.html", map[string]any{})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		renderTemplate(w, "register.html", map[string]any{"Error": "Invalid form submission"})
+		return
+	}
+	username := r.FormValue("username")
+	email := r.FormValue("email")
+	password := r.FormValue("password")
+	if username == "" || email == "" || password == "" {
+		renderTemplate(w, "register.html", map[string]any{"Error": "All fields are required"})
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		renderTemplate(w, "register.html", map[string]any{"Error": "Failed to register: " + err.Error()})
+		return
+	}
+
+	res, err := db.Exec(
+		"INSERT INTO users (username, email, password_hash, created_at, verified) VALUES (?, ?, ?, ?, 0)",
+		username, email, string(passwordHash), time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		renderTemplate(w, "register.html", map[string]any{"Error": "Failed to register: " + err.Error()})
+		return
+	}
+	userID, _ := res.LastInsertId()
+
+	token, err := generateToken()
+	if err != nil {
+		renderTemplate(w, "register.html", map[string]any{"Error": "Failed to register: " + err.Error()})
+		return
+	}
+	expiresAt := time.Now().UTC().Add(24 * time.Hour)
+	_, err = db.Exec(
+		"INSERT INTO verification_tokens (user_id, token_hash, created_at, expires_at, used) VALUES (?, ?, ?, ?, 0)",
+		userID, hashToken(token), time.Now().UTC().Format(time.RFC3339), expiresAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		renderTemplate(w, "register.html", map[string]any{"Error": "Failed to register: " + err.Error()})
+		return
+	}
+
+	link := cfg.Section("general").Key("baseurl").String() + "/verify?token=" + token
+	if err := sendVerificationEmail(email, link); err != nil {
+		slogger.Error("failed to send verification email", "err", err)
+	}
+
+	renderTemplate(w, "register_sent.html", map[string]any{"Email": email})
+}
+
+func verifyHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	var id, userID int64
+	var expiresAt string
+	var used bool
+	err := db.QueryRow(
+		"SELECT id, user_id, expires_at, used FROM verification_tokens WHERE token_hash = ?",
+		hashToken(token),
+	).Scan(&id, &userID, &expiresAt, &used)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Invalid verification link", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to verify: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	expiry, err := time.Parse(time.RFC3339, expiresAt)
+	if used || err != nil || time.Now().UTC().After(expiry) {
+		http.Error(w, "This verification link has expired", http.StatusGone)
+		return
+	}
+
+	if _, err := db.Exec("UPDATE users SET verified = 1 WHERE id = ?", userID); err != nil {
+		http.Error(w, "Failed to verify: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	db.Exec("UPDATE verification_tokens SET used = 1 WHERE id = ?", id)
+
+	renderTemplate(w, "login.html", map[string]any{"Message": "Account verified, you can log in now"})
+}
+
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		renderTemplate(w, "login.html", map[string]any{})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		renderTemplate(w, "login.html", map[string]any{"Error": "Invalid form submission"})
+		return
+	}
+	username := r.FormValue("username")
+	password := r.FormValue("password")
+
+	var userID int64
+	var passwordHash string
+	var verified bool
+	err := db.QueryRow("SELECT id, password_hash, verified FROM users WHERE username = ?", username).Scan(&userID, &passwordHash, &verified)
+	if err != nil {
+		// Compare against a dummy hash even on a missing user, so a bad
+		// username doesn't return noticeably faster than a bad password and
+		// leak which usernames are registered via response timing.
+		passwordHash = dummyPasswordHash
+	}
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil || err != nil {
+		renderTemplate(w, "login.html", map[string]any{"Error": "Invalid username or password"})
+		return
+	}
+	if !verified {
+		renderTemplate(w, "login.html", map[string]any{"Error": "Please verify your email before logging in"})
+		return
+	}
+
+	sessionID, err := generateToken()
+	if err != nil {
+		renderTemplate(w, "login.html", map[string]any{"Error": "Failed to log in: " + err.Error()})
+		return
+	}
+	expiresAt := time.Now().UTC().Add(7 * 24 * time.Hour)
+	_, err = db.Exec(
+		"INSERT INTO sessions (session_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		hashSessionID(sessionID), userID, time.Now().UTC().Format(time.RFC3339), expiresAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		renderTemplate(w, "login.html", map[string]any{"Error": "Failed to log in: " + err.Error()})
+		return
+	}
+
+	setSessionCookie(w, sessionID, expiresAt)
+	http.Redirect(w, r, "/dashboard", http.StatusFound)
+}
+
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil && cookie.Value != "" {
+		sep := len(cookie.Value) - 64
+		if sep > 0 {
+			db.Exec("DELETE FROM sessions WHERE session_hash = ?", hashSessionID(cookie.Value[:sep-1]))
+		}
+	}
+	clearSessionCookie(w)
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
+
+type dashboardEntry struct {
+	Tag  string
+	URL  string
+	Uses int64
+}
+
+func dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	user := userFromSession(r)
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusFound)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			renderTemplate(w, "dashboard.html", map[string]any{"Error": "Invalid form submission"})
+			return
+		}
+		tag := r.FormValue("tag")
+		switch r.FormValue("action") {
+		case "update":
+			db.Exec("UPDATE entries SET url = ? WHERE tag = ? AND user_id = ?", r.FormValue("url"), tag, user.ID)
+		case "delete":
+			db.Exec("DELETE FROM entries WHERE tag = ? AND user_id = ?", tag, user.ID)
+		}
+	}
+
+	rows, err := db.Query("SELECT tag, url, uses FROM entries WHERE user_id = ? ORDER BY tag", user.ID)
+	if err != nil {
+		renderTemplate(w, "dashboard.html", map[string]any{"Error": "Failed to load entries: " + err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	entries := []dashboardEntry{}
+	for rows.Next() {
+		var e dashboardEntry
+		if err := rows.Scan(&e.Tag, &e.URL, &e.Uses); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	renderTemplate(w, "dashboard.html", map[string]any{"Entries": entries})
+}